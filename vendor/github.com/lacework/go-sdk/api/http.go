@@ -20,28 +20,64 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"time"
 
 	"go.uber.org/zap"
 )
 
-// NewRequest generates a new http request
-func (c *Client) NewRequest(method string, apiURL string, body io.Reader) (*http.Request, error) {
+// NewRequest generates a new http request, it is a thin wrapper around
+// NewRequestContext using context.Background()
+func (c *Client) NewRequest(method string, apiURL string, body io.Reader, opts ...RequestOption) (*http.Request, error) {
+	return c.NewRequestContext(context.Background(), method, apiURL, body, opts...)
+}
+
+// NewRequestContext is like NewRequest but it attaches ctx to the
+// generated request via http.NewRequestWithContext, allowing callers to
+// cancel or time out long-running list/search calls
+func (c *Client) NewRequestContext(ctx context.Context, method string, apiURL string, body io.Reader, opts ...RequestOption) (*http.Request, error) {
+	cfg := &requestConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.body != nil {
+		body = cfg.body
+	}
+
 	apiPath, err := url.Parse(c.apiPath(apiURL))
 	if err != nil {
 		return nil, err
 	}
 
 	u := c.baseURL.ResolveReference(apiPath)
-	request, err := http.NewRequest(method, u.String(), body)
+	request, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
 		return nil, err
 	}
 
+	// make the request body replayable so that retries (see WithRetry) can
+	// resend it, http.NewRequest already does this for the common buffer
+	// types, snapshot anything else into memory
+	if body != nil && request.GetBody == nil {
+		snapshot, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := request.Body.Close(); err != nil {
+			return nil, err
+		}
+
+		request.Body = ioutil.NopCloser(bytes.NewReader(snapshot))
+		request.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(snapshot)), nil
+		}
+	}
+
 	// set all necessary headers
 	headers := map[string]string{
 		"Method": request.Method,
@@ -55,23 +91,41 @@ func (c *Client) NewRequest(method string, apiURL string, body io.Reader) (*http
 		// verify that the client has a token or token is not expired,
 		// if not, try to generate one
 		if c.auth.token == "" || c.TokenExpired() {
-			// run token expired callback
-			if c.callbacks.TokenExpiredCallback != nil && c.TokenExpired() {
+			refreshed := false
+
+			// TokenExpiredCallbackContext is the context-aware sibling of the
+			// existing TokenExpiredCallback, e.g. NewAuthenticatorCallback for
+			// an OAuth2Authenticator, and is expected to actually refresh
+			// c.auth.token rather than merely observe the expiry
+			if c.callbacks.TokenExpiredCallbackContext != nil {
+				if err := c.callbacks.TokenExpiredCallbackContext(ctx); err != nil {
+					c.log.Info("token expired callback failure", zap.String("error", err.Error()))
+				} else {
+					refreshed = true
+				}
+			} else if c.callbacks.TokenExpiredCallback != nil && c.TokenExpired() {
+				// the legacy callback predates context support and is kept as
+				// a context-less notification hook for backward compatibility
 				if err := c.callbacks.TokenExpiredCallback(); err != nil {
 					c.log.Info("token expired callback failure", zap.String("error", err.Error()))
 				}
 			}
-			if _, err = c.GenerateToken(); err != nil {
-				return nil, err
+
+			// fall back to the key-secret exchange unless a context-aware
+			// callback above actually populated the token, an authenticator
+			// that returns nil without doing so should not leave us sending
+			// an unauthenticated request
+			if !refreshed || c.auth.token == "" {
+				if _, err = c.GenerateTokenContext(ctx); err != nil {
+					return nil, err
+				}
 			}
 		}
 		headers["Authorization"] = c.auth.token
 	}
 
 	if body != nil {
-		// @afiune we should detect the content-type from the body
-		// instead of hard-coding it here
-		headers["Content-Type"] = "application/json"
+		headers["Content-Type"] = negotiateContentType(body, cfg)
 	}
 
 	for k, v := range headers {
@@ -136,9 +190,16 @@ func (c *Client) DoDecoder(req *http.Request, v interface{}) (*http.Response, er
 }
 
 // RequestDecoder performs an http request on an endpoint, and
-// decodes the response into the provided interface, all at once
+// decodes the response into the provided interface, all at once. It is a
+// thin wrapper around RequestDecoderContext using context.Background()
 func (c *Client) RequestDecoder(method, path string, body io.Reader, v interface{}) error {
-	request, err := c.NewRequest(method, path, body)
+	return c.RequestDecoderContext(context.Background(), method, path, body, v)
+}
+
+// RequestDecoderContext is like RequestDecoder but it threads ctx through
+// to the underlying request, allowing the caller to cancel or time it out
+func (c *Client) RequestDecoderContext(ctx context.Context, method, path string, body io.Reader, v interface{}, opts ...RequestOption) error {
+	request, err := c.NewRequestContext(ctx, method, path, body, opts...)
 	if err != nil {
 		return err
 	}
@@ -153,17 +214,82 @@ func (c *Client) RequestDecoder(method, path string, body io.Reader, v interface
 }
 
 // RequestEncoderDecoder leverages RequestDecoder and performs an http request that first
-// encodes the provider 'data' as a JSON Reader and passes it as the body to the request
+// encodes the provider 'data' as a JSON Reader and passes it as the body to the request.
+// It is a thin wrapper around RequestEncoderDecoderContext using context.Background()
 func (c *Client) RequestEncoderDecoder(method, path string, data, v interface{}) error {
+	return c.RequestEncoderDecoderContext(context.Background(), method, path, data, v)
+}
+
+// RequestEncoderDecoderContext is like RequestEncoderDecoder but it threads
+// ctx through to the underlying request
+func (c *Client) RequestEncoderDecoderContext(ctx context.Context, method, path string, data, v interface{}) error {
 	body, err := jsonReader(data)
 	if err != nil {
 		return err
 	}
-	return c.RequestDecoder(method, path, body, v)
+	return c.RequestDecoderContext(ctx, method, path, body, v)
 }
 
-// Do calls request.Do() directly
+// Do calls request.Do() directly, transparently retrying the request
+// according to the client's retry policy (see WithRetry) when configured.
+// It is a thin wrapper around DoContext using the request's own context
 func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.DoContext(req.Context(), req)
+}
+
+// DoContext is like Do but it attaches ctx to req before executing it,
+// so that retries and backoff sleeps abort as soon as ctx is done
+func (c *Client) DoContext(ctx context.Context, req *http.Request) (*http.Response, error) {
+	req = req.WithContext(ctx)
+
+	if c.retry == nil {
+		return c.doOnce(req)
+	}
+
+	var (
+		response *http.Response
+		err      error
+	)
+
+	for attempt := 1; ; attempt++ {
+		response, err = c.doOnce(req)
+
+		if attempt >= c.retry.max || !c.retry.policy.ShouldRetry(response, err, attempt) {
+			return response, err
+		}
+
+		sleep := retryAfter(response)
+		if sleep == 0 {
+			sleep = fullJitterBackoff(c.retry.base, c.retry.cap, attempt)
+		}
+
+		c.log.Info("retrying request",
+			zap.Int("attempt", attempt),
+			zap.Int("status", statusCode(response)),
+			zap.Duration("sleep", sleep),
+		)
+
+		if response != nil {
+			response.Body.Close()
+		}
+
+		if req.GetBody != nil {
+			if newBody, bodyErr := req.GetBody(); bodyErr == nil && newBody != nil {
+				req.Body = newBody
+			}
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(sleep):
+		}
+	}
+}
+
+// doOnce performs a single HTTP round-trip, it is the non-retrying
+// building block that Do loops over when a retry policy is configured
+func (c *Client) doOnce(req *http.Request) (*http.Response, error) {
 	response, err := c.c.Do(req)
 	if err == nil {
 		c.log.Info("response",
@@ -185,6 +311,13 @@ func (c *Client) Do(req *http.Request) (*http.Response, error) {
 	return response, err
 }
 
+func statusCode(res *http.Response) int {
+	if res == nil {
+		return 0
+	}
+	return res.StatusCode
+}
+
 // httpHeadersSniffer is only useful to avoid logging out the headers of a request
 // or response when the log level is set to INFO
 func (c *Client) httpHeadersSniffer(headers interface{}) interface{} {
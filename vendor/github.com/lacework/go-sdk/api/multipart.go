@@ -0,0 +1,146 @@
+//
+// Author:: Salim Afiune Maya (<afiune@lacework.net>)
+// Copyright:: Copyright 2020, Lacework Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+// requestConfig is the set of knobs a RequestOption can tweak on a request
+// generated by NewRequest/NewRequestContext
+type requestConfig struct {
+	contentType string
+	body        io.Reader
+	multipart   bool
+	sniff       bool
+}
+
+// RequestOption configures a request generated by NewRequest, in the
+// functional-option style
+type RequestOption func(*requestConfig)
+
+// WithContentType forces the Content-Type header instead of letting
+// NewRequest negotiate one from the body
+func WithContentType(contentType string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.contentType = contentType
+	}
+}
+
+// WithBody overrides the request body, useful when a helper like
+// NewMultipartRequest needs to build the body itself
+func WithBody(body io.Reader) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.body = body
+	}
+}
+
+// WithMultipart marks the request body as multipart/form-data whose
+// Content-Type (boundary included) has already been set via
+// WithContentType, so content-type negotiation is skipped
+func WithMultipart() RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.multipart = true
+	}
+}
+
+// WithContentSniffing opts a *bytes.Buffer/*bytes.Reader body into
+// Content-Type detection via http.DetectContentType instead of the
+// historical application/json default. Most callers send JSON and should
+// leave this unset; it exists for endpoints whose body isn't JSON but
+// that don't know their Content-Type up front (use WithContentType when
+// they do)
+func WithContentSniffing() RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.sniff = true
+	}
+}
+
+// negotiateContentType picks the Content-Type header for a request body:
+// an explicit WithContentType/WithMultipart option always wins; otherwise
+// it defaults to the historical application/json, unless the caller opted
+// into sniffing buffer-backed bodies with WithContentSniffing
+func negotiateContentType(body io.Reader, cfg *requestConfig) string {
+	if cfg != nil && (cfg.contentType != "" || cfg.multipart) {
+		return cfg.contentType
+	}
+
+	if cfg != nil && cfg.sniff {
+		switch b := body.(type) {
+		case *bytes.Buffer:
+			return http.DetectContentType(sniff(b.Bytes()))
+		case *bytes.Reader:
+			buf := make([]byte, 512)
+			n, _ := b.ReadAt(buf, 0)
+			return http.DetectContentType(buf[:n])
+		}
+	}
+
+	return "application/json"
+}
+
+func sniff(data []byte) []byte {
+	if len(data) > 512 {
+		return data[:512]
+	}
+	return data
+}
+
+// NewMultipartRequest builds a multipart/form-data request out of a set of
+// plain form fields and files, this unblocks endpoints that accept file
+// uploads, such as custom policy bundles or evidence attachments on alerts
+func (c *Client) NewMultipartRequest(method, path string, fields map[string]string, files map[string]io.Reader) (*http.Request, error) {
+	return c.NewMultipartRequestContext(context.Background(), method, path, fields, files)
+}
+
+// NewMultipartRequestContext is like NewMultipartRequest but it threads
+// ctx through to the underlying request
+func (c *Client) NewMultipartRequestContext(ctx context.Context, method, path string, fields map[string]string, files map[string]io.Reader) (*http.Request, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, reader := range files {
+		part, err := writer.CreateFormFile(name, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(part, reader); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return c.NewRequestContext(ctx, method, path, &buf,
+		WithMultipart(),
+		WithContentType(writer.FormDataContentType()),
+	)
+}
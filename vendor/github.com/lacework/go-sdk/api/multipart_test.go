@@ -0,0 +1,86 @@
+//
+// Author:: Salim Afiune Maya (<afiune@lacework.net>)
+// Copyright:: Copyright 2020, Lacework Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package api
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNegotiateContentTypeDefaultsToJSON(t *testing.T) {
+	body := bytes.NewBufferString(`{"foo":"bar"}`)
+
+	if got := negotiateContentType(body, &requestConfig{}); got != "application/json" {
+		t.Errorf("expected application/json by default, got %q", got)
+	}
+}
+
+func TestNegotiateContentTypeDefaultsToJSONWithNilConfig(t *testing.T) {
+	body := bytes.NewBufferString(`{"foo":"bar"}`)
+
+	if got := negotiateContentType(body, nil); got != "application/json" {
+		t.Errorf("expected application/json with a nil config, got %q", got)
+	}
+}
+
+func TestNegotiateContentTypeExplicitOverride(t *testing.T) {
+	body := bytes.NewBufferString(`{"foo":"bar"}`)
+	cfg := &requestConfig{contentType: "application/vnd.lacework+json"}
+
+	if got := negotiateContentType(body, cfg); got != "application/vnd.lacework+json" {
+		t.Errorf("expected the explicit content type to win, got %q", got)
+	}
+}
+
+func TestNegotiateContentTypeMultipartWins(t *testing.T) {
+	body := bytes.NewBufferString("--boundary--")
+	cfg := &requestConfig{multipart: true, contentType: "multipart/form-data; boundary=boundary"}
+
+	if got := negotiateContentType(body, cfg); got != "multipart/form-data; boundary=boundary" {
+		t.Errorf("expected the multipart content type to win, got %q", got)
+	}
+}
+
+func TestNegotiateContentTypeSniffsWhenOptedIn(t *testing.T) {
+	body := bytes.NewBufferString("plain text, not JSON")
+	cfg := &requestConfig{sniff: true}
+
+	got := negotiateContentType(body, cfg)
+	if got == "application/json" {
+		t.Errorf("expected sniffing to override the JSON default, got %q", got)
+	}
+}
+
+func TestNegotiateContentTypeSniffsBytesReader(t *testing.T) {
+	body := bytes.NewReader([]byte("plain text, not JSON"))
+	cfg := &requestConfig{sniff: true}
+
+	got := negotiateContentType(body, cfg)
+	if got == "application/json" {
+		t.Errorf("expected sniffing a *bytes.Reader to override the JSON default, got %q", got)
+	}
+}
+
+func TestNegotiateContentTypeBytesReaderDefaultsToJSON(t *testing.T) {
+	body := bytes.NewReader([]byte(`{"foo":"bar"}`))
+
+	if got := negotiateContentType(body, &requestConfig{}); got != "application/json" {
+		t.Errorf("expected application/json for a *bytes.Reader body when sniffing is not requested, got %q", got)
+	}
+}
@@ -0,0 +1,380 @@
+//
+// Author:: Salim Afiune Maya (<afiune@lacework.net>)
+// Copyright:: Copyright 2020, Lacework Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Authenticator abstracts how a Client obtains the bearer token it attaches
+// to every authenticated request. It lets callers swap the default
+// key-secret exchange for, say, an OAuth2 flow when Lacework is fronted by
+// an SSO/OIDC identity provider
+type Authenticator interface {
+	// Token returns a valid access token, generating or refreshing it as
+	// needed, along with the time at which it expires
+	Token(ctx context.Context) (string, time.Time, error)
+}
+
+// KeySecretAuthenticator is the default Authenticator, it wraps the
+// existing X-LW-UAKS key-secret exchange performed by Client.GenerateToken
+type KeySecretAuthenticator struct {
+	client *Client
+}
+
+// NewKeySecretAuthenticator builds an Authenticator around the standard
+// key-secret token exchange that this client already supports
+func NewKeySecretAuthenticator(c *Client) *KeySecretAuthenticator {
+	return &KeySecretAuthenticator{client: c}
+}
+
+// Token generates a new access token via the key-secret exchange. The
+// returned expiration is tracked internally by the client's TokenExpired
+// check, so callers should rely on Client.TokenExpired rather than the
+// zero time returned here
+func (a *KeySecretAuthenticator) Token(ctx context.Context) (string, time.Time, error) {
+	if _, err := a.client.GenerateTokenContext(ctx); err != nil {
+		return "", time.Time{}, err
+	}
+	return a.client.auth.token, time.Time{}, nil
+}
+
+// oauth2Credentials is the on-disk shape persisted to credentialsPath so
+// that a subsequent process doesn't need to re-prompt the user
+type oauth2Credentials struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+func (c oauth2Credentials) expired() bool {
+	return c.AccessToken == "" || time.Now().After(c.Expiry)
+}
+
+// OAuth2Authenticator implements an OAuth2 authorization-code-with-PKCE
+// flow suitable for CLI use, for environments where Lacework sits behind
+// an SSO/OIDC identity provider instead of accepting key-secret pairs
+type OAuth2Authenticator struct {
+	// Issuer is the base URL of the OIDC provider, e.g. https://sso.example.com
+	Issuer string
+	// ClientID is the OAuth2 client id registered with the issuer
+	ClientID string
+	// CredentialsPath overrides where tokens are cached, defaults to
+	// ~/.config/lacework/credentials.json
+	CredentialsPath string
+	// OpenBrowser, when set, is used to launch the authorize URL in the
+	// user's browser. When nil, the URL is only printed to stderr
+	OpenBrowser func(authorizeURL string) error
+
+	httpClient *http.Client
+	log        *zap.Logger
+}
+
+// NewOAuth2Authenticator builds an Authenticator that performs the
+// authorization-code-with-PKCE flow against the given OIDC issuer
+func NewOAuth2Authenticator(issuer, clientID string) *OAuth2Authenticator {
+	return &OAuth2Authenticator{
+		Issuer:     issuer,
+		ClientID:   clientID,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		log:        zap.NewNop(),
+	}
+}
+
+// Token returns a cached, non-expired access token, silently refreshing it
+// with the stored refresh token if needed, or driving the full PKCE login
+// flow when no usable credentials exist yet
+func (a *OAuth2Authenticator) Token(ctx context.Context) (string, time.Time, error) {
+	creds, err := a.loadCredentials()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if creds != nil && !creds.expired() {
+		return creds.AccessToken, creds.Expiry, nil
+	}
+
+	if creds != nil && creds.RefreshToken != "" {
+		refreshed, err := a.refresh(ctx, creds.RefreshToken)
+		if err == nil {
+			if err := a.saveCredentials(refreshed); err != nil {
+				return "", time.Time{}, err
+			}
+			return refreshed.AccessToken, refreshed.Expiry, nil
+		}
+		a.log.Info("oauth2 refresh failed, falling back to interactive login",
+			zap.String("error", err.Error()))
+	}
+
+	logged, err := a.login(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if err := a.saveCredentials(logged); err != nil {
+		return "", time.Time{}, err
+	}
+	return logged.AccessToken, logged.Expiry, nil
+}
+
+// login drives the interactive authorization-code-with-PKCE exchange: it
+// generates a code_verifier/code_challenge pair, opens a loopback listener
+// to receive the redirect, sends the user to the issuer's authorize
+// endpoint, and exchanges the returned code for tokens
+func (a *OAuth2Authenticator) login(ctx context.Context) (*oauth2Credentials, error) {
+	verifier, err := randomCodeVerifier()
+	if err != nil {
+		return nil, err
+	}
+	challenge := codeChallengeS256(verifier)
+
+	state, err := randomState()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("unable to start loopback listener: %w", err)
+	}
+	defer listener.Close()
+
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", listener.Addr().(*net.TCPAddr).Port)
+
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if got := r.URL.Query().Get("state"); got != state {
+				errCh <- fmt.Errorf("oauth2: state mismatch")
+				http.Error(w, "state mismatch", http.StatusBadRequest)
+				return
+			}
+			if errMsg := r.URL.Query().Get("error"); errMsg != "" {
+				errCh <- fmt.Errorf("oauth2: authorize error: %s", errMsg)
+				http.Error(w, errMsg, http.StatusBadRequest)
+				return
+			}
+
+			code := r.URL.Query().Get("code")
+			if code == "" {
+				errCh <- fmt.Errorf("oauth2: missing authorization code")
+				http.Error(w, "missing authorization code", http.StatusBadRequest)
+				return
+			}
+
+			fmt.Fprint(w, "Authentication complete, you may close this window.")
+			codeCh <- code
+		}),
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authorizeURL := a.authorizeURL(redirectURI, challenge, state)
+	if a.OpenBrowser != nil {
+		if err := a.OpenBrowser(authorizeURL); err != nil {
+			a.log.Info("unable to open browser, falling back to printing the URL",
+				zap.String("error", err.Error()))
+			fmt.Fprintf(os.Stderr, "Open the following URL to authenticate:\n\n%s\n\n", authorizeURL)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "Open the following URL to authenticate:\n\n%s\n\n", authorizeURL)
+	}
+
+	select {
+	case code := <-codeCh:
+		return a.exchangeCode(ctx, code, verifier, redirectURI)
+	case err := <-errCh:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (a *OAuth2Authenticator) authorizeURL(redirectURI, challenge, state string) string {
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", a.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	q.Set("state", state)
+
+	return fmt.Sprintf("%s/authorize?%s", a.Issuer, q.Encode())
+}
+
+func (a *OAuth2Authenticator) exchangeCode(ctx context.Context, code, verifier, redirectURI string) (*oauth2Credentials, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("client_id", a.ClientID)
+	form.Set("code", code)
+	form.Set("code_verifier", verifier)
+	form.Set("redirect_uri", redirectURI)
+
+	return a.token(ctx, form)
+}
+
+func (a *OAuth2Authenticator) refresh(ctx context.Context, refreshToken string) (*oauth2Credentials, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("client_id", a.ClientID)
+	form.Set("refresh_token", refreshToken)
+
+	return a.token(ctx, form)
+}
+
+func (a *OAuth2Authenticator) token(ctx context.Context, form url.Values) (*oauth2Credentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/oauth/token", a.Issuer), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oauth2: token endpoint returned %s", res.Status)
+	}
+
+	var payload struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	return &oauth2Credentials{
+		AccessToken:  payload.AccessToken,
+		RefreshToken: payload.RefreshToken,
+		Expiry:       time.Now().Add(time.Duration(payload.ExpiresIn) * time.Second),
+	}, nil
+}
+
+func (a *OAuth2Authenticator) credentialsPath() (string, error) {
+	if a.CredentialsPath != "" {
+		return a.CredentialsPath, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "lacework", "credentials.json"), nil
+}
+
+func (a *OAuth2Authenticator) loadCredentials() (*oauth2Credentials, error) {
+	path, err := a.credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var creds oauth2Credentials
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, err
+	}
+	return &creds, nil
+}
+
+func (a *OAuth2Authenticator) saveCredentials(creds *oauth2Credentials) error {
+	path, err := a.credentialsPath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, raw, 0600)
+}
+
+// NewAuthenticatorCallback returns a TokenExpiredCallbackContext that
+// refreshes a Client's token through the provided Authenticator, honoring
+// the ctx passed down from the request that triggered the refresh. Pass it
+// to WithTokenExpiredCallbackContext() when constructing the Client to
+// swap the default key-secret exchange for, e.g., an OAuth2Authenticator.
+// This is the context-aware sibling of the pre-existing
+// WithTokenExpiredCallback(), kept as a plain notification hook for
+// backward compatibility
+func NewAuthenticatorCallback(c *Client, a Authenticator) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		token, _, err := a.Token(ctx)
+		if err != nil {
+			return err
+		}
+		c.auth.token = token
+		return nil
+	}
+}
+
+func randomCodeVerifier() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func randomState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func codeChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,252 @@
+//
+// Author:: Salim Afiune Maya (<afiune@lacework.net>)
+// Copyright:: Copyright 2020, Lacework Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// queryTag is the struct tag that NewRequestWithQuery looks for when
+// encoding a struct into URL query parameters, it mirrors the convention
+// popularized by google/go-querystring: `url:"name,omitempty"`
+const queryTag = "url"
+
+// NewRequestWithQuery is like NewRequest but it also accepts an arbitrary
+// struct that gets encoded into the request's query string. This avoids
+// the manual url.Values fiddling that list/search endpoints would
+// otherwise require
+func (c *Client) NewRequestWithQuery(method string, apiURL string, query interface{}, body io.Reader) (*http.Request, error) {
+	request, err := c.NewRequest(method, apiURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := queryValues(query)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(values) != 0 {
+		existing := request.URL.Query()
+		for k, v := range values {
+			existing[k] = append(existing[k], v...)
+		}
+		request.URL.RawQuery = existing.Encode()
+	}
+
+	return request, nil
+}
+
+// RequestQueryDecoder is the RequestDecoder sibling that threads a typed
+// query struct through to the underlying request, encoding it as URL
+// query parameters before decoding the response into v
+func (c *Client) RequestQueryDecoder(method, path string, query interface{}, body io.Reader, v interface{}) error {
+	request, err := c.NewRequestWithQuery(method, path, query, body)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.DoDecoder(request, v)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return err
+}
+
+// queryValues walks the provided struct with reflect and encodes its
+// exported fields into url.Values, honoring `url:"name,omitempty"` tags,
+// the comma/semicolon/space delimiter option for slices, and recursing
+// into embedded structs. A nil query, or a nil pointer to one, returns
+// empty values; zero-value fields are dropped only when tagged omitempty
+func queryValues(query interface{}) (url.Values, error) {
+	values := url.Values{}
+	if query == nil {
+		return values, nil
+	}
+
+	v := reflect.ValueOf(query)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return values, nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("query: expected a struct, got %s", v.Kind())
+	}
+
+	if err := encodeStruct(v, values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func encodeStruct(v reflect.Value, values url.Values) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		// unexported fields are skipped, mirrors encoding/json behavior
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+
+		fv := v.Field(i)
+		name, opts, omitted := parseQueryTag(sf)
+		if omitted {
+			continue
+		}
+
+		if sf.Anonymous && name == "" {
+			ev := fv
+			for ev.Kind() == reflect.Ptr {
+				if ev.IsNil() {
+					break
+				}
+				ev = ev.Elem()
+			}
+			if ev.Kind() == reflect.Struct {
+				if err := encodeStruct(ev, values); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		if err := encodeField(name, fv, opts, values); err != nil {
+			return fmt.Errorf("query: field %q: %w", sf.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func parseQueryTag(sf reflect.StructField) (name string, opts []string, omitted bool) {
+	tag := sf.Tag.Get(queryTag)
+	if tag == "-" {
+		return "", nil, true
+	}
+
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if len(parts) > 1 {
+		opts = parts[1:]
+	}
+	return name, opts, false
+}
+
+func hasOption(opts []string, option string) bool {
+	for _, o := range opts {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+func delimiter(opts []string) string {
+	switch {
+	case hasOption(opts, "semicolon"):
+		return ";"
+	case hasOption(opts, "space"):
+		return " "
+	case hasOption(opts, "comma"):
+		return ","
+	default:
+		return ""
+	}
+}
+
+func encodeField(name string, v reflect.Value, opts []string, values url.Values) error {
+	omitempty := hasOption(opts, "omitempty")
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if omitempty {
+				return nil
+			}
+			values.Add(name, "")
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		if omitempty && t.IsZero() {
+			return nil
+		}
+		values.Add(name, t.Format(time.RFC3339))
+		return nil
+	}
+
+	if omitempty && isZero(v) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 {
+			return nil
+		}
+		if d := delimiter(opts); d != "" {
+			items := make([]string, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				items[i] = fmt.Sprint(v.Index(i).Interface())
+			}
+			values.Add(name, strings.Join(items, d))
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			values.Add(name, fmt.Sprint(v.Index(i).Interface()))
+		}
+		return nil
+	case reflect.Struct:
+		return encodeStruct(v, values)
+	case reflect.Bool:
+		values.Add(name, strconv.FormatBool(v.Bool()))
+		return nil
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		values.Add(name, fmt.Sprint(v.Interface()))
+		return nil
+	default:
+		return fmt.Errorf("unsupported kind %s", v.Kind())
+	}
+}
+
+func isZero(v reflect.Value) bool {
+	return v.IsZero()
+}
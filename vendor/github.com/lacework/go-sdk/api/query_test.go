@@ -0,0 +1,115 @@
+//
+// Author:: Salim Afiune Maya (<afiune@lacework.net>)
+// Copyright:: Copyright 2020, Lacework Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package api
+
+import "testing"
+
+type testPagination struct {
+	Page int `url:"page,omitempty"`
+}
+
+type testListQuery struct {
+	testPagination
+	Name  string   `url:"name,omitempty"`
+	Tags  []string `url:"tags,omitempty,comma"`
+	Limit *int     `url:"limit,omitempty"`
+}
+
+type unsupportedQuery struct {
+	Data map[string]string `url:"data"`
+}
+
+func TestQueryValuesZeroValue(t *testing.T) {
+	values, err := queryValues(testListQuery{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 0 {
+		t.Fatalf("expected no query params for a zero-value struct, got %v", values)
+	}
+}
+
+func TestQueryValuesNilQuery(t *testing.T) {
+	values, err := queryValues(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(values) != 0 {
+		t.Fatalf("expected no query params for a nil query, got %v", values)
+	}
+}
+
+func TestQueryValuesScalarAndEmbedded(t *testing.T) {
+	query := testListQuery{
+		testPagination: testPagination{Page: 2},
+		Name:           "my-alert",
+	}
+
+	values, err := queryValues(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := values.Get("page"); got != "2" {
+		t.Errorf("expected page=2, got %q", got)
+	}
+	if got := values.Get("name"); got != "my-alert" {
+		t.Errorf("expected name=my-alert, got %q", got)
+	}
+	if values.Get("tags") != "" {
+		t.Errorf("expected tags to be omitted, got %q", values.Get("tags"))
+	}
+	if values.Get("limit") != "" {
+		t.Errorf("expected limit to be omitted, got %q", values.Get("limit"))
+	}
+}
+
+func TestQueryValuesSliceDelimiter(t *testing.T) {
+	query := testListQuery{Tags: []string{"prod", "aws", "critical"}}
+
+	values, err := queryValues(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := values.Get("tags"); got != "prod,aws,critical" {
+		t.Errorf("expected comma-delimited tags, got %q", got)
+	}
+}
+
+func TestQueryValuesPointerField(t *testing.T) {
+	limit := 50
+	query := testListQuery{Limit: &limit}
+
+	values, err := queryValues(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := values.Get("limit"); got != "50" {
+		t.Errorf("expected limit=50, got %q", got)
+	}
+}
+
+func TestQueryValuesUnsupportedKind(t *testing.T) {
+	_, err := queryValues(unsupportedQuery{Data: map[string]string{"a": "b"}})
+	if err == nil {
+		t.Fatal("expected an error for an unsupported field kind, got nil")
+	}
+}
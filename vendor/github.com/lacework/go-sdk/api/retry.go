@@ -0,0 +1,142 @@
+//
+// Author:: Salim Afiune Maya (<afiune@lacework.net>)
+// Copyright:: Copyright 2020, Lacework Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package api
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides whether Client.Do should retry a request, given the
+// response (nil on a network error), the error observed (nil on a normal
+// HTTP response) and the number of attempts made so far, starting at 1
+type RetryPolicy interface {
+	ShouldRetry(res *http.Response, err error, attempt int) bool
+}
+
+// defaultRetryPolicy retries network errors, 429 Too Many Requests, and
+// every 5xx response except 501 Not Implemented, which is never transient
+type defaultRetryPolicy struct{}
+
+func (defaultRetryPolicy) ShouldRetry(res *http.Response, err error, _ int) bool {
+	if err != nil {
+		return true
+	}
+	if res == nil {
+		return false
+	}
+
+	if res.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	return res.StatusCode >= 500 && res.StatusCode != http.StatusNotImplemented
+}
+
+// retryConfig holds the tunables configured via WithRetry / WithRetryPolicy
+type retryConfig struct {
+	max    int
+	base   time.Duration
+	cap    time.Duration
+	policy RetryPolicy
+}
+
+// retryOption adapts a plain function into an Option
+type retryOption func(c *Client) error
+
+func (f retryOption) apply(c *Client) error { return f(c) }
+
+// WithRetry configures the Client to transparently retry failed requests,
+// up to max attempts, using full-jitter exponential backoff seeded by base
+// and bounded by cap: sleep = rand(0, min(cap, base*2^attempt))
+func WithRetry(max int, base, cap time.Duration) Option {
+	return retryOption(func(c *Client) error {
+		var policy RetryPolicy = defaultRetryPolicy{}
+		if c.retry != nil && c.retry.policy != nil {
+			// keep a policy set by an earlier WithRetryPolicy
+			policy = c.retry.policy
+		}
+		c.retry = &retryConfig{max: max, base: base, cap: cap, policy: policy}
+		return nil
+	})
+}
+
+// WithRetryPolicy overrides the predicate used to decide whether a
+// response or error should be retried, it must be used together with
+// WithRetry which controls the attempt count and backoff timing
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return retryOption(func(c *Client) error {
+		if c.retry == nil {
+			c.retry = &retryConfig{policy: policy}
+		} else {
+			c.retry.policy = policy
+		}
+		return nil
+	})
+}
+
+// fullJitterBackoff implements the "full jitter" strategy described in
+// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+func fullJitterBackoff(base, cap time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	max := float64(base) * math.Pow(2, float64(attempt))
+	if cap > 0 && max > float64(cap) {
+		max = float64(cap)
+	}
+
+	return time.Duration(rand.Int63n(int64(max) + 1))
+}
+
+// retryAfter parses a Retry-After header off of a 429/503 response, in
+// either the delta-seconds or the HTTP-date form, and returns zero when
+// absent or invalid so the caller falls back to exponential backoff
+func retryAfter(res *http.Response) time.Duration {
+	if res == nil {
+		return 0
+	}
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if when, err := http.ParseTime(value); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,127 @@
+//
+// Author:: Salim Afiune Maya (<afiune@lacework.net>)
+// Copyright:: Copyright 2020, Lacework Inc.
+// License:: Apache License, Version 2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFullJitterBackoffBounds(t *testing.T) {
+	base := 100 * time.Millisecond
+	cap := 2 * time.Second
+
+	for attempt := 1; attempt <= 10; attempt++ {
+		for i := 0; i < 20; i++ {
+			sleep := fullJitterBackoff(base, cap, attempt)
+			if sleep < 0 {
+				t.Fatalf("attempt %d: sleep went negative: %v", attempt, sleep)
+			}
+			if sleep > cap {
+				t.Fatalf("attempt %d: sleep %v exceeded cap %v", attempt, sleep, cap)
+			}
+		}
+	}
+}
+
+func TestFullJitterBackoffZeroBase(t *testing.T) {
+	if sleep := fullJitterBackoff(0, time.Second, 3); sleep != 0 {
+		t.Fatalf("expected zero sleep for zero base, got %v", sleep)
+	}
+}
+
+func TestRetryAfterDeltaSeconds(t *testing.T) {
+	res := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"5"}},
+	}
+
+	if got := retryAfter(res); got != 5*time.Second {
+		t.Errorf("expected 5s, got %v", got)
+	}
+}
+
+func TestRetryAfterHTTPDate(t *testing.T) {
+	when := time.Now().Add(10 * time.Second).UTC()
+	res := &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"Retry-After": []string{when.Format(http.TimeFormat)}},
+	}
+
+	got := retryAfter(res)
+	if got <= 0 || got > 10*time.Second {
+		t.Errorf("expected a positive duration close to 10s, got %v", got)
+	}
+}
+
+func TestRetryAfterAbsentOrIrrelevantStatus(t *testing.T) {
+	if got := retryAfter(nil); got != 0 {
+		t.Errorf("expected 0 for a nil response, got %v", got)
+	}
+
+	ok := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Retry-After": []string{"5"}}}
+	if got := retryAfter(ok); got != 0 {
+		t.Errorf("expected 0 for a 200 response even with a Retry-After header, got %v", got)
+	}
+
+	noHeader := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	if got := retryAfter(noHeader); got != 0 {
+		t.Errorf("expected 0 when Retry-After is absent, got %v", got)
+	}
+}
+
+func TestDefaultRetryPolicyShouldRetry(t *testing.T) {
+	policy := defaultRetryPolicy{}
+
+	cases := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{"network error", 0, errTest, true},
+		{"429", http.StatusTooManyRequests, nil, true},
+		{"500", http.StatusInternalServerError, nil, true},
+		{"501 not implemented", http.StatusNotImplemented, nil, false},
+		{"200 ok", http.StatusOK, nil, false},
+		{"404 not found", http.StatusNotFound, nil, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var res *http.Response
+			if c.err == nil {
+				res = httptest.NewRecorder().Result()
+				res.StatusCode = c.status
+			}
+
+			if got := policy.ShouldRetry(res, c.err, 1); got != c.want {
+				t.Errorf("ShouldRetry() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+var errTest = &testError{"boom"}
+
+type testError struct{ msg string }
+
+func (e *testError) Error() string { return e.msg }